@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// chunksCovering returns the manifest chunks that intersect the requested
+// [rowStart, rowEnd] x [colStart, colEnd) rectangle (rows inclusive,
+// columns exclusive, matching ChunkInfo's own convention).
+func chunksCovering(manifest *Manifest, rowStart, rowEnd, colStart, colEnd int) []ChunkInfo {
+	var covering []ChunkInfo
+	for _, chunk := range manifest.Chunks {
+		if chunk.RowEnd < rowStart || chunk.RowStart > rowEnd {
+			continue
+		}
+		if chunk.ColEnd <= colStart || chunk.ColStart >= colEnd {
+			continue
+		}
+		covering = append(covering, chunk)
+	}
+	return covering
+}
+
+// fetchChunkRows returns just the data rows of chunk that fall within
+// [rowStart, rowEnd], using a Range request when the manifest recorded
+// per-row byte offsets so the whole chunk object doesn't need downloading.
+// Range offsets are only recorded for uncompressed chunks (see upload.go),
+// so compressed chunks always take the whole-object fallback below.
+func (s *server) fetchChunkRows(r *http.Request, chunk ChunkInfo, rowStart, rowEnd int, comp compression) ([][]string, error) {
+	localStart := max(0, rowStart-chunk.RowStart)
+	localEnd := min(chunk.RowEnd, rowEnd) - chunk.RowStart // inclusive, local to the chunk
+
+	if len(chunk.RowByteOffsets) > localEnd+1 {
+		rc, err := s.store.GetChunkRange(r.Context(), chunk.Key, chunk.RowByteOffsets[localStart], chunk.RowByteOffsets[localEnd+1])
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+
+		rows, err := csv.NewReader(rc).ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse range response for chunk %s: %w", chunk.Key, err)
+		}
+		return rows, nil
+	}
+
+	// No (or incomplete) byte-offset index: fall back to reading the whole
+	// chunk, decompressing it if needed, skip its header line, and select
+	// the rows we need in memory.
+	rc, err := s.store.GetChunk(r.Context(), chunk.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	body, err := unwrapForCompression(rc, comp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress chunk %s: %w", chunk.Key, err)
+	}
+	defer body.Close()
+
+	allRows, err := csv.NewReader(body).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse chunk %s: %w", chunk.Key, err)
+	}
+	if len(allRows) > 0 {
+		allRows = allRows[1:] // drop header
+	}
+	if localStart >= len(allRows) {
+		return nil, nil
+	}
+	return allRows[localStart : min(localEnd, len(allRows)-1)+1], nil
+}
+
+// cellsHandler serves GET /doc/{docId}/cells?rowStart=&rowEnd=&colStart=&colEnd=.
+// It consults the manifest to find the minimal set of chunks covering the
+// requested rectangle, fetches just the needed rows out of each (via Range
+// requests where the manifest makes that possible), and stitches the
+// result back together.
+func (s *server) cellsHandler(w http.ResponseWriter, r *http.Request) {
+	docId, err := uuid.Parse(r.PathValue("docId"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid docId: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	rowStart, errRS := strconv.Atoi(r.URL.Query().Get("rowStart"))
+	rowEnd, errRE := strconv.Atoi(r.URL.Query().Get("rowEnd"))
+	colStart, errCS := strconv.Atoi(r.URL.Query().Get("colStart"))
+	colEnd, errCE := strconv.Atoi(r.URL.Query().Get("colEnd"))
+	if errRS != nil || errRE != nil || errCS != nil || errCE != nil ||
+		rowStart < 0 || colStart < 0 || rowStart > rowEnd || colStart > colEnd {
+		http.Error(w, "rowStart, rowEnd, colStart, colEnd must be non-negative integers with start <= end", http.StatusBadRequest)
+		return
+	}
+
+	manifest, err := s.readManifest(r.Context(), docId.String())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Manifest not found for docId %s: %v", docId, err), http.StatusNotFound)
+		return
+	}
+	if rowStart > manifest.NumRows-1 || colStart > manifest.NumCols-1 {
+		http.Error(w, fmt.Sprintf("Requested range is outside docId %s's bounds (%d rows x %d cols)", docId, manifest.NumRows, manifest.NumCols), http.StatusNotFound)
+		return
+	}
+	rowEnd = min(rowEnd, manifest.NumRows-1)
+	colEnd = min(colEnd, manifest.NumCols)
+
+	result := make([][]string, rowEnd-rowStart+1)
+	for i := range result {
+		result[i] = make([]string, colEnd-colStart)
+	}
+
+	comp, err := parseCompression(manifest.Compression)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Manifest for docId %s has an unreadable compression setting: %v", docId, err), http.StatusInternalServerError)
+		return
+	}
+
+	for _, chunk := range chunksCovering(manifest, rowStart, rowEnd, colStart, colEnd) {
+		rows, err := s.fetchChunkRows(r, chunk, rowStart, rowEnd, comp)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to read chunk %s: %v", chunk.Key, err), http.StatusInternalServerError)
+			return
+		}
+
+		firstRow := max(rowStart, chunk.RowStart)
+		overlapColStart := max(colStart, chunk.ColStart)
+		overlapColEnd := min(colEnd, chunk.ColEnd)
+
+		for i, row := range rows {
+			gr := firstRow + i
+			if gr > rowEnd {
+				break
+			}
+			for gc := overlapColStart; gc < overlapColEnd; gc++ {
+				localCol := gc - chunk.ColStart
+				if localCol < len(row) {
+					result[gr-rowStart][gc-colStart] = row[localCol]
+				}
+			}
+		}
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	default:
+		w.Header().Set("Content-Type", "text/csv")
+		csvWriter := csv.NewWriter(w)
+		csvWriter.WriteAll(result)
+	}
+}
+
+// chunkHandler serves GET /doc/{docId}/chunk/{key}, streaming a single raw
+// chunk object back to the caller so browsers and other services can read
+// it without direct store credentials. The manifest's compression setting
+// determines the Content-Encoding, since chunk bodies are stored compressed
+// as-is rather than being decompressed on the way out.
+func (s *server) chunkHandler(w http.ResponseWriter, r *http.Request) {
+	docId, err := uuid.Parse(r.PathValue("docId"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid docId: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	manifest, err := s.readManifest(r.Context(), docId.String())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Manifest not found for docId %s: %v", docId, err), http.StatusNotFound)
+		return
+	}
+
+	key := docId.String() + "/" + r.PathValue("key")
+	rc, err := s.store.GetChunk(r.Context(), key)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Chunk not found: %v", err), http.StatusNotFound)
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", "text/csv")
+	if enc := compression(manifest.Compression).contentEncoding(); enc != "" {
+		w.Header().Set("Content-Encoding", enc)
+	}
+	if _, err := io.Copy(w, rc); err != nil {
+		log.Printf("Failed to stream chunk %s: %v", key, err)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// wrapForCompression wraps w so writes are compressed according to c
+// before reaching the underlying stream. Close on the returned
+// io.WriteCloser flushes the compressor's footer and then closes w, so
+// the upload isn't finalized with a truncated stream.
+func wrapForCompression(w io.WriteCloser, c compression) (io.WriteCloser, error) {
+	switch c {
+	case compressionNone, "":
+		return w, nil
+	case compressionGzip:
+		return &chainedWriteCloser{Writer: gzip.NewWriter(w), inner: w}, nil
+	case compressionZstd:
+		enc, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd encoder: %w", err)
+		}
+		return &chainedWriteCloser{Writer: enc, inner: w}, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", c)
+	}
+}
+
+// unwrapForCompression wraps r so reads are decompressed according to c.
+// Used when reading a chunk back out for the cells endpoint, which needs
+// to parse its CSV content rather than just proxy the bytes. The caller
+// must Close the returned io.ReadCloser: a zstd decoder in particular
+// holds background goroutines and buffers that are only released on Close.
+func unwrapForCompression(r io.Reader, c compression) (io.ReadCloser, error) {
+	switch c {
+	case compressionNone, "":
+		return io.NopCloser(r), nil
+	case compressionGzip:
+		return gzip.NewReader(r)
+	case compressionZstd:
+		dec, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd decoder: %w", err)
+		}
+		return dec.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", c)
+	}
+}
+
+// chainedWriteCloser closes an outer compressing writer (flushing its
+// footer) before closing the inner stream it wraps.
+type chainedWriteCloser struct {
+	io.Writer
+	inner io.WriteCloser
+}
+
+func (c *chainedWriteCloser) Close() error {
+	if closer, ok := c.Writer.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			c.inner.Close()
+			return err
+		}
+	}
+	return c.inner.Close()
+}
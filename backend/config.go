@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// Defaults and sane bounds for chunk dimensions, overridable via the
+// CHUNK_ROWS/CHUNK_COLS environment variables and the chunkRows/chunkCols
+// form fields on a per-request basis.
+const (
+	defaultChunkRows = 1000 // Example: chunk every 1000 rows
+	defaultChunkCols = 50   // Example: chunk every 50 columns
+
+	minChunkRows        = 1
+	maxChunkRowsAllowed = 100_000
+	minChunkCols        = 1
+	maxChunkColsAllowed = 10_000
+)
+
+// compression identifies how a chunk's CSV body is encoded before upload.
+type compression string
+
+const (
+	compressionNone compression = "none"
+	compressionGzip compression = "gzip"
+	compressionZstd compression = "zstd"
+)
+
+// extension returns the file extension a chunk key should carry for c,
+// e.g. ".gz" for compressionGzip, "" for compressionNone.
+func (c compression) extension() string {
+	switch c {
+	case compressionGzip:
+		return ".gz"
+	case compressionZstd:
+		return ".zst"
+	default:
+		return ""
+	}
+}
+
+// contentEncoding returns the HTTP Content-Encoding value for c, or "" if
+// none applies.
+func (c compression) contentEncoding() string {
+	switch c {
+	case compressionGzip:
+		return "gzip"
+	case compressionZstd:
+		return "zstd"
+	default:
+		return ""
+	}
+}
+
+func parseCompression(v string) (compression, error) {
+	switch compression(v) {
+	case "", compressionNone:
+		return compressionNone, nil
+	case compressionGzip:
+		return compressionGzip, nil
+	case compressionZstd:
+		return compressionZstd, nil
+	default:
+		return "", fmt.Errorf("unsupported compression %q (want none, gzip, or zstd)", v)
+	}
+}
+
+// chunkConfig is the resolved set of chunk dimension and compression
+// settings for one upload: env defaults, then any per-request form
+// overrides.
+type chunkConfig struct {
+	rows        int
+	cols        int
+	compression compression
+}
+
+// defaultChunkConfig reads CHUNK_ROWS, CHUNK_COLS, and CHUNK_COMPRESSION
+// from the environment, falling back to defaultChunkRows/defaultChunkCols/
+// compressionNone when unset.
+func defaultChunkConfig() (chunkConfig, error) {
+	cfg := chunkConfig{rows: defaultChunkRows, cols: defaultChunkCols, compression: compressionNone}
+
+	if v := os.Getenv("CHUNK_ROWS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid CHUNK_ROWS %q: %w", v, err)
+		}
+		cfg.rows = n
+	}
+	if v := os.Getenv("CHUNK_COLS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid CHUNK_COLS %q: %w", v, err)
+		}
+		cfg.cols = n
+	}
+	if v := os.Getenv("CHUNK_COMPRESSION"); v != "" {
+		c, err := parseCompression(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid CHUNK_COMPRESSION: %w", err)
+		}
+		cfg.compression = c
+	}
+
+	return cfg, cfg.validate()
+}
+
+// withFormOverrides returns a copy of cfg with any chunkRows, chunkCols,
+// or compression form fields present on r applied.
+func (cfg chunkConfig) withFormOverrides(r *http.Request) (chunkConfig, error) {
+	out := cfg
+
+	if v := r.FormValue("chunkRows"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return out, fmt.Errorf("invalid chunkRows %q: %w", v, err)
+		}
+		out.rows = n
+	}
+	if v := r.FormValue("chunkCols"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return out, fmt.Errorf("invalid chunkCols %q: %w", v, err)
+		}
+		out.cols = n
+	}
+	if v := r.FormValue("compression"); v != "" {
+		c, err := parseCompression(v)
+		if err != nil {
+			return out, err
+		}
+		out.compression = c
+	}
+
+	return out, out.validate()
+}
+
+func (cfg chunkConfig) validate() error {
+	if cfg.rows < minChunkRows || cfg.rows > maxChunkRowsAllowed {
+		return fmt.Errorf("chunkRows must be between %d and %d", minChunkRows, maxChunkRowsAllowed)
+	}
+	if cfg.cols < minChunkCols || cfg.cols > maxChunkColsAllowed {
+		return fmt.Errorf("chunkCols must be between %d and %d", minChunkCols, maxChunkColsAllowed)
+	}
+	return nil
+}
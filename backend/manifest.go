@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ChunkInfo describes one uploaded chunk object in a document's manifest:
+// the key it was stored under, its size, and the row/column rectangle of
+// the original sheet it covers.
+type ChunkInfo struct {
+	Key      string `json:"key"`
+	Size     int64  `json:"size"`
+	RowStart int    `json:"rowStart"`
+	RowEnd   int    `json:"rowEnd"`
+	ColStart int    `json:"colStart"`
+	ColEnd   int    `json:"colEnd"`
+
+	// RowByteOffsets holds the byte offset of each data row within the
+	// chunk object (the header line is excluded), plus a trailing
+	// end-of-object sentinel, so a reader can fetch just the rows it needs
+	// with an HTTP Range request instead of the whole chunk. Has
+	// len(rows-in-chunk)+1 entries; nil for compressed chunks and for
+	// manifests written before this was tracked.
+	RowByteOffsets []int64 `json:"rowByteOffsets,omitempty"`
+}
+
+// Manifest describes the chunk layout produced by uploading a docId's
+// file, so downstream consumers can discover it without listing the
+// underlying store.
+type Manifest struct {
+	DocId       string      `json:"docId"`
+	Filename    string      `json:"filename"`
+	ContentHash string      `json:"contentHash"`
+	UploadedAt  time.Time   `json:"uploadedAt"`
+	NumRows     int         `json:"numRows"`
+	NumCols     int         `json:"numCols"`
+	ChunkRows   int         `json:"chunkRows"`
+	ChunkCols   int         `json:"chunkCols"`
+	Compression string      `json:"compression"`
+	Chunks      []ChunkInfo `json:"chunks"`
+}
+
+// manifestKey returns the key the manifest for docId is stored under.
+func manifestKey(docId string) string {
+	return fmt.Sprintf("%s/manifest.json", docId)
+}
+
+func (s *server) writeManifest(ctx context.Context, m *Manifest) error {
+	body, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest for docId %s: %w", m.DocId, err)
+	}
+	if err := s.store.PutChunk(ctx, manifestKey(m.DocId), bytes.NewReader(body), int64(len(body))); err != nil {
+		return fmt.Errorf("failed to write manifest for docId %s: %w", m.DocId, err)
+	}
+	return nil
+}
+
+func (s *server) readManifest(ctx context.Context, docId string) (*Manifest, error) {
+	rc, err := s.store.GetChunk(ctx, manifestKey(docId))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest for docId %s: %w", docId, err)
+	}
+	defer rc.Close()
+
+	var m Manifest
+	if err := json.NewDecoder(rc).Decode(&m); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest for docId %s: %w", docId, err)
+	}
+	return &m, nil
+}
+
+// deleteDoc removes every chunk belonging to docId, plus its manifest. It
+// prefers the manifest's chunk list so it doesn't need to list the store;
+// if no manifest exists (e.g. a previous upload was interrupted before one
+// was written), it falls back to a prefix scan so orphaned chunks still
+// get cleaned up.
+func (s *server) deleteDoc(ctx context.Context, docId string) (int, error) {
+	manifest, err := s.readManifest(ctx, docId)
+	if err != nil {
+		return s.store.DeleteChunksByPrefix(ctx, docId+"/")
+	}
+
+	keys := make([]string, 0, len(manifest.Chunks)+1)
+	for _, chunk := range manifest.Chunks {
+		keys = append(keys, chunk.Key)
+	}
+	keys = append(keys, manifestKey(docId))
+
+	return s.store.DeleteChunks(ctx, keys)
+}
+
+// getManifestHandler serves GET /doc/{docId}/manifest.
+func (s *server) getManifestHandler(w http.ResponseWriter, r *http.Request) {
+	docId, err := uuid.Parse(r.PathValue("docId"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid docId: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	manifest, err := s.readManifest(r.Context(), docId.String())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Manifest not found for docId %s: %v", docId, err), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(manifest); err != nil {
+		log.Printf("Failed to write manifest response for docId %s: %v", docId, err)
+	}
+}
+
+// deleteDocHandler serves DELETE /doc/{docId}, removing every chunk (and
+// the manifest) belonging to docId.
+func (s *server) deleteDocHandler(w http.ResponseWriter, r *http.Request) {
+	docId, err := uuid.Parse(r.PathValue("docId"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid docId: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := s.deleteDoc(r.Context(), docId.String())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete docId %s: %v", docId, err), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprintf(w, "Deleted %d object(s) for docId '%s'.", deleted, docId.String())
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// newTestServer builds a server backed by a localChunkStore rooted in a
+// fresh t.TempDir(), with small chunk dimensions so a handful of rows/cols
+// is enough to exercise row- and column-band rotation.
+func newTestServer(t *testing.T) *server {
+	t.Helper()
+	t.Setenv("LOCAL_STORAGE_DIR", t.TempDir())
+
+	store, err := newLocalChunkStore()
+	if err != nil {
+		t.Fatalf("newLocalChunkStore: %v", err)
+	}
+	return &server{
+		store:         store,
+		defaultChunks: chunkConfig{rows: 2, cols: 2, compression: compressionNone},
+	}
+}
+
+// newUploadRequest builds a POST /upload request carrying csvBody as a
+// multipart file upload for docId, with any extra form fields (e.g.
+// "compression") set alongside it.
+func newUploadRequest(t *testing.T, docId, csvBody string, fields map[string]string) *http.Request {
+	t.Helper()
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fw, err := mw.CreateFormFile("file", "test.csv")
+	if err != nil {
+		t.Fatalf("CreateFormFile: %v", err)
+	}
+	if _, err := fw.Write([]byte(csvBody)); err != nil {
+		t.Fatalf("write csv body: %v", err)
+	}
+	if err := mw.WriteField("docId", docId); err != nil {
+		t.Fatalf("WriteField docId: %v", err)
+	}
+	for k, v := range fields {
+		if err := mw.WriteField(k, v); err != nil {
+			t.Fatalf("WriteField %s: %v", k, err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatalf("close multipart writer: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestUploadManifestCellsDeleteRoundTrip(t *testing.T) {
+	srv := newTestServer(t)
+	docId := uuid.New().String()
+	csvBody := "a,b,c,d\n1,2,3,4\n5,6,7,8\n9,10,11,12\n"
+
+	uploadRec := httptest.NewRecorder()
+	srv.uploadHandler(uploadRec, newUploadRequest(t, docId, csvBody, nil))
+	if uploadRec.Code != http.StatusOK {
+		t.Fatalf("upload: got status %d, body %q", uploadRec.Code, uploadRec.Body.String())
+	}
+
+	manifestReq := httptest.NewRequest(http.MethodGet, "/doc/"+docId+"/manifest", nil)
+	manifestReq.SetPathValue("docId", docId)
+	manifestRec := httptest.NewRecorder()
+	srv.getManifestHandler(manifestRec, manifestReq)
+	if manifestRec.Code != http.StatusOK {
+		t.Fatalf("manifest: got status %d, body %q", manifestRec.Code, manifestRec.Body.String())
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(manifestRec.Body.Bytes(), &manifest); err != nil {
+		t.Fatalf("decode manifest: %v", err)
+	}
+	if manifest.NumRows != 3 || manifest.NumCols != 4 {
+		t.Fatalf("manifest dims = %d rows x %d cols, want 3 x 4", manifest.NumRows, manifest.NumCols)
+	}
+	// 2 row-bands (rows 0-1, row 2) x 2 col-bands (cols 0-1, cols 2-3).
+	if len(manifest.Chunks) != 4 {
+		t.Fatalf("manifest has %d chunks, want 4", len(manifest.Chunks))
+	}
+
+	cellsReq := httptest.NewRequest(http.MethodGet, "/doc/"+docId+"/cells?rowStart=0&rowEnd=2&colStart=0&colEnd=4", nil)
+	cellsReq.SetPathValue("docId", docId)
+	cellsRec := httptest.NewRecorder()
+	srv.cellsHandler(cellsRec, cellsReq)
+	if cellsRec.Code != http.StatusOK {
+		t.Fatalf("cells: got status %d, body %q", cellsRec.Code, cellsRec.Body.String())
+	}
+
+	gotRows, err := csv.NewReader(cellsRec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("parse cells response: %v", err)
+	}
+	wantRows := [][]string{{"1", "2", "3", "4"}, {"5", "6", "7", "8"}, {"9", "10", "11", "12"}}
+	if len(gotRows) != len(wantRows) {
+		t.Fatalf("cells rows = %v, want %v", gotRows, wantRows)
+	}
+	for i := range wantRows {
+		for j := range wantRows[i] {
+			if gotRows[i][j] != wantRows[i][j] {
+				t.Errorf("cell [%d][%d] = %q, want %q", i, j, gotRows[i][j], wantRows[i][j])
+			}
+		}
+	}
+
+	deleteReq := httptest.NewRequest(http.MethodDelete, "/doc/"+docId, nil)
+	deleteReq.SetPathValue("docId", docId)
+	deleteRec := httptest.NewRecorder()
+	srv.deleteDocHandler(deleteRec, deleteReq)
+	if deleteRec.Code != http.StatusOK {
+		t.Fatalf("delete: got status %d, body %q", deleteRec.Code, deleteRec.Body.String())
+	}
+
+	manifestRec2 := httptest.NewRecorder()
+	srv.getManifestHandler(manifestRec2, manifestReq)
+	if manifestRec2.Code != http.StatusNotFound {
+		t.Fatalf("manifest after delete: got status %d, want 404", manifestRec2.Code)
+	}
+}
+
+func TestCellsHandlerRejectsOutOfRangeRequests(t *testing.T) {
+	srv := newTestServer(t)
+	docId := uuid.New().String()
+	csvBody := "a,b\n1,2\n3,4\n"
+
+	uploadRec := httptest.NewRecorder()
+	srv.uploadHandler(uploadRec, newUploadRequest(t, docId, csvBody, nil))
+	if uploadRec.Code != http.StatusOK {
+		t.Fatalf("upload: got status %d, body %q", uploadRec.Code, uploadRec.Body.String())
+	}
+
+	cases := []struct {
+		name       string
+		query      string
+		wantStatus int
+	}{
+		{"rowStart past end of document", "rowStart=1000000&rowEnd=1000000&colStart=0&colEnd=2", http.StatusNotFound},
+		{"large negative colStart", "rowStart=0&rowEnd=1&colStart=-2000000000&colEnd=2", http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/doc/"+docId+"/cells?"+tc.query, nil)
+			req.SetPathValue("docId", docId)
+			rec := httptest.NewRecorder()
+			srv.cellsHandler(rec, req)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("got status %d, want %d (body %q)", rec.Code, tc.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestUploadCompressionRoundTrip(t *testing.T) {
+	for _, comp := range []string{"gzip", "zstd"} {
+		t.Run(comp, func(t *testing.T) {
+			srv := newTestServer(t)
+			docId := uuid.New().String()
+			csvBody := "a,b,c,d\n1,2,3,4\n5,6,7,8\n9,10,11,12\n"
+
+			uploadRec := httptest.NewRecorder()
+			srv.uploadHandler(uploadRec, newUploadRequest(t, docId, csvBody, map[string]string{"compression": comp}))
+			if uploadRec.Code != http.StatusOK {
+				t.Fatalf("upload: got status %d, body %q", uploadRec.Code, uploadRec.Body.String())
+			}
+
+			manifestReq := httptest.NewRequest(http.MethodGet, "/doc/"+docId+"/manifest", nil)
+			manifestReq.SetPathValue("docId", docId)
+			manifestRec := httptest.NewRecorder()
+			srv.getManifestHandler(manifestRec, manifestReq)
+			if manifestRec.Code != http.StatusOK {
+				t.Fatalf("manifest: got status %d, body %q", manifestRec.Code, manifestRec.Body.String())
+			}
+
+			var manifest Manifest
+			if err := json.Unmarshal(manifestRec.Body.Bytes(), &manifest); err != nil {
+				t.Fatalf("decode manifest: %v", err)
+			}
+			if manifest.Compression != comp {
+				t.Fatalf("manifest.Compression = %q, want %q", manifest.Compression, comp)
+			}
+			if len(manifest.Chunks) == 0 {
+				t.Fatalf("manifest has no chunks")
+			}
+			for _, chunk := range manifest.Chunks {
+				if chunk.RowByteOffsets != nil {
+					t.Errorf("chunk %s has RowByteOffsets set, want nil for compressed chunks", chunk.Key)
+				}
+			}
+
+			// /cells must decompress the whole-chunk fallback and stitch
+			// the rows back together correctly.
+			cellsReq := httptest.NewRequest(http.MethodGet, "/doc/"+docId+"/cells?rowStart=0&rowEnd=2&colStart=0&colEnd=4", nil)
+			cellsReq.SetPathValue("docId", docId)
+			cellsRec := httptest.NewRecorder()
+			srv.cellsHandler(cellsRec, cellsReq)
+			if cellsRec.Code != http.StatusOK {
+				t.Fatalf("cells: got status %d, body %q", cellsRec.Code, cellsRec.Body.String())
+			}
+			gotRows, err := csv.NewReader(cellsRec.Body).ReadAll()
+			if err != nil {
+				t.Fatalf("parse cells response: %v", err)
+			}
+			wantRows := [][]string{{"1", "2", "3", "4"}, {"5", "6", "7", "8"}, {"9", "10", "11", "12"}}
+			if len(gotRows) != len(wantRows) {
+				t.Fatalf("cells rows = %v, want %v", gotRows, wantRows)
+			}
+			for i := range wantRows {
+				for j := range wantRows[i] {
+					if gotRows[i][j] != wantRows[i][j] {
+						t.Errorf("cell [%d][%d] = %q, want %q", i, j, gotRows[i][j], wantRows[i][j])
+					}
+				}
+			}
+
+			// /chunk/{key} must proxy the raw compressed bytes and label
+			// them with a matching Content-Encoding.
+			firstChunk := manifest.Chunks[0]
+			chunkKey := strings.TrimPrefix(firstChunk.Key, docId+"/")
+			chunkReq := httptest.NewRequest(http.MethodGet, "/doc/"+docId+"/chunk/"+chunkKey, nil)
+			chunkReq.SetPathValue("docId", docId)
+			chunkReq.SetPathValue("key", chunkKey)
+			chunkRec := httptest.NewRecorder()
+			srv.chunkHandler(chunkRec, chunkReq)
+			if chunkRec.Code != http.StatusOK {
+				t.Fatalf("chunk: got status %d, body %q", chunkRec.Code, chunkRec.Body.String())
+			}
+			wantEncoding := compression(comp).contentEncoding()
+			if got := chunkRec.Header().Get("Content-Encoding"); got != wantEncoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, wantEncoding)
+			}
+
+			decompressed, err := unwrapForCompression(chunkRec.Body, compression(comp))
+			if err != nil {
+				t.Fatalf("unwrapForCompression: %v", err)
+			}
+			defer decompressed.Close()
+			rows, err := csv.NewReader(decompressed).ReadAll()
+			if err != nil {
+				t.Fatalf("parse decompressed chunk: %v", err)
+			}
+			if len(rows) == 0 {
+				t.Fatalf("decompressed chunk has no rows")
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ChunkMetadata carries optional object metadata a ChunkStore
+// implementation should set where the backend supports it (S3's
+// Content-Type/Content-Encoding headers; ignored by the local store,
+// which has no equivalent).
+type ChunkMetadata struct {
+	ContentType     string
+	ContentEncoding string
+}
+
+// ChunkStore abstracts the object storage backend used to persist and
+// retrieve uploaded CSV chunks. It lets uploadHandler and friends stay
+// agnostic of whether chunks end up in AWS S3, an S3-compatible endpoint
+// (MinIO, Ceph RGW, Wasabi, ...), or on local disk.
+type ChunkStore interface {
+	// PutChunk uploads body (size bytes) under key, creating or overwriting
+	// the object.
+	PutChunk(ctx context.Context, key string, body io.Reader, size int64) error
+
+	// PutChunkStream starts an upload to key and returns a writer that
+	// streams into it, for callers that don't know the final size up
+	// front. Close finalizes the upload; if ctx is canceled before Close,
+	// the upload is aborted. meta carries object metadata (content type,
+	// encoding) that implementations set where the backend supports it.
+	PutChunkStream(ctx context.Context, key string, meta ChunkMetadata) (io.WriteCloser, error)
+
+	// GetChunk returns a reader for the object stored at key. The caller is
+	// responsible for closing the returned ReadCloser.
+	GetChunk(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// GetChunkRange returns a reader for the half-open byte range
+	// [start, end) of the object at key (end is exclusive). The caller is
+	// responsible for closing the returned ReadCloser.
+	GetChunkRange(ctx context.Context, key string, start, end int64) (io.ReadCloser, error)
+
+	// DeleteChunksByPrefix removes every object whose key starts with
+	// prefix and returns the number of objects deleted.
+	DeleteChunksByPrefix(ctx context.Context, prefix string) (int, error)
+
+	// DeleteChunks removes exactly the given keys, regardless of any
+	// common prefix, and returns the number successfully deleted.
+	DeleteChunks(ctx context.Context, keys []string) (int, error)
+
+	// ListChunks returns the keys of every object stored under prefix.
+	ListChunks(ctx context.Context, prefix string) ([]string, error)
+}
+
+// NewChunkStoreFromEnv builds the ChunkStore selected by the
+// UPLOADER_STORAGE_PROVIDER environment variable. Supported values are
+// "aws-s3" (the default), "s3-compatible", and "local".
+func NewChunkStoreFromEnv(ctx context.Context) (ChunkStore, error) {
+	provider := os.Getenv("UPLOADER_STORAGE_PROVIDER")
+	if provider == "" {
+		provider = "aws-s3"
+	}
+
+	switch provider {
+	case "aws-s3":
+		return newAWSS3ChunkStore(ctx)
+	case "s3-compatible":
+		return newS3CompatibleChunkStore(ctx)
+	case "local":
+		return newLocalChunkStore()
+	default:
+		return nil, fmt.Errorf("unknown UPLOADER_STORAGE_PROVIDER %q", provider)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// localChunkStore implements ChunkStore on the local filesystem, rooted at
+// baseDir. It exists for development and for unit-testing the HTTP
+// handlers without talking to S3.
+type localChunkStore struct {
+	baseDir string
+}
+
+// newLocalChunkStore builds a ChunkStore rooted at LOCAL_STORAGE_DIR
+// (default "./data"), creating the directory if it doesn't exist.
+func newLocalChunkStore() (*localChunkStore, error) {
+	baseDir := os.Getenv("LOCAL_STORAGE_DIR")
+	if baseDir == "" {
+		baseDir = "./data"
+	}
+	if err := os.MkdirAll(baseDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create local storage dir %s: %w", baseDir, err)
+	}
+	return &localChunkStore{baseDir: baseDir}, nil
+}
+
+func (s *localChunkStore) path(key string) string {
+	return filepath.Join(s.baseDir, filepath.FromSlash(key))
+}
+
+func (s *localChunkStore) PutChunk(ctx context.Context, key string, body io.Reader, size int64) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for chunk %s: %w", key, err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create chunk %s: %w", key, err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, body); err != nil {
+		return fmt.Errorf("failed to write chunk %s: %w", key, err)
+	}
+	return nil
+}
+
+// PutChunkStream ignores meta: plain files have no Content-Type/
+// Content-Encoding headers to set. It does not watch ctx either: canceling
+// ctx before Close leaves whatever was written so far in the file instead
+// of aborting the upload.
+func (s *localChunkStore) PutChunkStream(ctx context.Context, key string, meta ChunkMetadata) (io.WriteCloser, error) {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for chunk %s: %w", key, err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chunk %s: %w", key, err)
+	}
+	return f, nil
+}
+
+func (s *localChunkStore) GetChunk(ctx context.Context, key string) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open chunk %s: %w", key, err)
+	}
+	return f, nil
+}
+
+func (s *localChunkStore) GetChunkRange(ctx context.Context, key string, start, end int64) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open chunk %s: %w", key, err)
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to seek chunk %s to offset %d: %w", key, start, err)
+	}
+	return &limitedReadCloser{Reader: io.LimitReader(f, end-start), Closer: f}, nil
+}
+
+// limitedReadCloser pairs an io.Reader (typically an io.LimitReader) with
+// the underlying file it should close on Close.
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func (s *localChunkStore) DeleteChunksByPrefix(ctx context.Context, prefix string) (int, error) {
+	keys, err := s.ListChunks(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+	return s.DeleteChunks(ctx, keys)
+}
+
+func (s *localChunkStore) DeleteChunks(ctx context.Context, keys []string) (int, error) {
+	deleted := 0
+	for _, key := range keys {
+		if err := os.Remove(s.path(key)); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return deleted, fmt.Errorf("failed to delete chunk %s: %w", key, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+func (s *localChunkStore) ListChunks(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.Walk(s.baseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.baseDir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunks under %s: %w", prefix, err)
+	}
+	return keys, nil
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// maxDeleteObjectsBatch is the largest number of keys the S3 DeleteObjects
+// API accepts in a single call.
+const maxDeleteObjectsBatch = 1000
+
+// Defaults for the multipart upload tuning knobs, overridable via
+// S3_UPLOAD_PART_SIZE_BYTES and S3_UPLOAD_CONCURRENCY.
+const (
+	defaultUploadPartSizeBytes = 5 << 20 // 5 MiB, the S3 multipart minimum
+	defaultUploadConcurrency   = 5
+)
+
+// uploadTuningFromEnv reads the multipart part size and per-upload
+// concurrency from the environment, falling back to sane defaults.
+func uploadTuningFromEnv() (partSize int64, concurrency int) {
+	partSize = defaultUploadPartSizeBytes
+	if v := os.Getenv("S3_UPLOAD_PART_SIZE_BYTES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			partSize = n
+		}
+	}
+
+	concurrency = defaultUploadConcurrency
+	if v := os.Getenv("S3_UPLOAD_CONCURRENCY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			concurrency = n
+		}
+	}
+	return partSize, concurrency
+}
+
+// deleteS3Keys removes keys from bucket in batches of maxDeleteObjectsBatch
+// using the DeleteObjects batch API.
+func deleteS3Keys(ctx context.Context, client *s3.Client, bucket string, keys []string) error {
+	for start := 0; start < len(keys); start += maxDeleteObjectsBatch {
+		end := start + maxDeleteObjectsBatch
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		objects := make([]types.ObjectIdentifier, 0, end-start)
+		for _, key := range keys[start:end] {
+			objects = append(objects, types.ObjectIdentifier{Key: aws.String(key)})
+		}
+
+		out, err := client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &types.Delete{Objects: objects},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete %d chunk(s): %w", len(objects), err)
+		}
+		if len(out.Errors) > 0 {
+			first := out.Errors[0]
+			return fmt.Errorf("failed to delete %d of %d chunk(s), first %s: %s", len(out.Errors), len(objects), aws.ToString(first.Key), aws.ToString(first.Message))
+		}
+	}
+	return nil
+}
+
+// s3ChunkStore implements ChunkStore on top of the AWS SDK v2 S3 client.
+// The same implementation backs both the native AWS S3 provider and the
+// S3-compatible provider (MinIO, Ceph RGW, Wasabi, ...) - only client
+// construction differs between the two, see newAWSS3ChunkStore and
+// newS3CompatibleChunkStore below.
+type s3ChunkStore struct {
+	client      *s3.Client
+	bucket      string
+	partSize    int64
+	concurrency int
+}
+
+// newAWSS3ChunkStore builds a ChunkStore backed by AWS S3, using
+// AWS_S3_BUCKET and AWS_REGION from the environment.
+func newAWSS3ChunkStore(ctx context.Context) (*s3ChunkStore, error) {
+	bucket := os.Getenv("AWS_S3_BUCKET")
+	if bucket == "" {
+		return nil, fmt.Errorf("AWS_S3_BUCKET environment variable not set")
+	}
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		return nil, fmt.Errorf("AWS_REGION environment variable not set")
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	partSize, concurrency := uploadTuningFromEnv()
+	return &s3ChunkStore{client: s3.NewFromConfig(cfg), bucket: bucket, partSize: partSize, concurrency: concurrency}, nil
+}
+
+// newS3CompatibleChunkStore builds a ChunkStore for any S3-compatible
+// endpoint, configured via S3_ENDPOINT, S3_FORCE_PATH_STYLE, and
+// S3_DISABLE_SSL in addition to the usual AWS_S3_BUCKET and AWS_REGION.
+func newS3CompatibleChunkStore(ctx context.Context) (*s3ChunkStore, error) {
+	bucket := os.Getenv("AWS_S3_BUCKET")
+	if bucket == "" {
+		return nil, fmt.Errorf("AWS_S3_BUCKET environment variable not set")
+	}
+	endpoint := os.Getenv("S3_ENDPOINT")
+	if endpoint == "" {
+		return nil, fmt.Errorf("S3_ENDPOINT environment variable not set")
+	}
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		// Most S3-compatible servers ignore the region, but the SDK still
+		// requires a non-empty value to sign requests.
+		region = "us-east-1"
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	forcePathStyle, _ := strconv.ParseBool(os.Getenv("S3_FORCE_PATH_STYLE"))
+	disableSSL, _ := strconv.ParseBool(os.Getenv("S3_DISABLE_SSL"))
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(endpointWithScheme(endpoint, disableSSL))
+		o.UsePathStyle = forcePathStyle
+	})
+
+	partSize, concurrency := uploadTuningFromEnv()
+	return &s3ChunkStore{client: client, bucket: bucket, partSize: partSize, concurrency: concurrency}, nil
+}
+
+func endpointWithScheme(endpoint string, disableSSL bool) string {
+	if strings.Contains(endpoint, "://") {
+		return endpoint
+	}
+	scheme := "https"
+	if disableSSL {
+		scheme = "http"
+	}
+	return scheme + "://" + endpoint
+}
+
+func (s *s3ChunkStore) PutChunk(ctx context.Context, key string, body io.Reader, size int64) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		Body:          body,
+		ContentLength: aws.Int64(size),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload chunk %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *s3ChunkStore) GetChunk(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch chunk %s: %w", key, err)
+	}
+	return out.Body, nil
+}
+
+// PutChunkStream starts a multipart upload to key and returns a writer that
+// streams into it, so the caller never has to buffer the whole chunk in
+// memory. It's implemented with an io.Pipe feeding a
+// aws-sdk-go-v2/feature/s3/manager.Uploader running in its own goroutine;
+// if ctx is canceled before the upload completes, the SDK aborts the
+// multipart upload and any parts already sent are discarded. Until Close
+// is called or ctx is canceled, that goroutine keeps waiting for more data.
+func (s *s3ChunkStore) PutChunkStream(ctx context.Context, key string, meta ChunkMetadata) (io.WriteCloser, error) {
+	pr, pw := io.Pipe()
+
+	uploader := manager.NewUploader(s.client, func(u *manager.Uploader) {
+		u.PartSize = s.partSize
+		u.Concurrency = s.concurrency
+	})
+
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   pr,
+	}
+	if meta.ContentType != "" {
+		input.ContentType = aws.String(meta.ContentType)
+	}
+	if meta.ContentEncoding != "" {
+		input.ContentEncoding = aws.String(meta.ContentEncoding)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := uploader.Upload(ctx, input)
+		pr.CloseWithError(err)
+		done <- err
+	}()
+
+	return &s3StreamWriter{pw: pw, done: done}, nil
+}
+
+// s3StreamWriter adapts an io.Pipe into the io.WriteCloser PutChunkStream
+// returns: writes feed the multipart upload running in the background
+// goroutine, and Close waits for that upload to finish (or abort) before
+// returning its result.
+type s3StreamWriter struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+func (w *s3StreamWriter) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+func (w *s3StreamWriter) Close() error {
+	if err := w.pw.Close(); err != nil {
+		return err
+	}
+	return <-w.done
+}
+
+func (s *s3ChunkStore) GetChunkRange(ctx context.Context, key string, start, end int64) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", start, end-1)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch range %d-%d of chunk %s: %w", start, end-1, key, err)
+	}
+	return out.Body, nil
+}
+
+func (s *s3ChunkStore) DeleteChunksByPrefix(ctx context.Context, prefix string) (int, error) {
+	keys, err := s.ListChunks(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+	return s.DeleteChunks(ctx, keys)
+}
+
+func (s *s3ChunkStore) DeleteChunks(ctx context.Context, keys []string) (int, error) {
+	if err := deleteS3Keys(ctx, s.client, s.bucket, keys); err != nil {
+		return 0, err
+	}
+	return len(keys), nil
+}
+
+func (s *s3ChunkStore) ListChunks(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list chunks under %s: %w", prefix, err)
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.ToString(obj.Key))
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// stagingBufSize is the size a column band's staging buffer grows to
+// before it's flushed to the band's upload pipe, amortizing the cost of
+// writing one small encoded row at a time.
+const stagingBufSize = 32 * 1024
+
+// stagingBufPool recycles the []byte buffers column bands stage encoded
+// rows in before flushing, so peak memory is bounded by concurrency ×
+// partSize × columnBands instead of growing with the size of the upload.
+var stagingBufPool = sync.Pool{
+	New: func() any { return make([]byte, 0, stagingBufSize) },
+}
+
+// columnBand streams one vertical slice (colStart:colEnd) of the sheet to
+// its own chunk object, rotating to a new chunk every chunkConfig.rows rows.
+type columnBand struct {
+	colStart, colEnd int
+	header           []string
+
+	rowStart     int // first data row index (post-header) of the open chunk
+	rowsInChunk  int
+	key          string
+	bytesInChunk int64
+	rowOffsets   []int64 // byte offset of each data row written so far (header excluded)
+
+	writer   io.WriteCloser
+	counter  *countingWriteCloser // tracks actual stored bytes, beneath any compression
+	stageBuf []byte
+}
+
+// offset returns the byte position, within the chunk object, that the next
+// write will land at: bytes already flushed plus whatever's still staged.
+func (band *columnBand) offset() int64 {
+	return band.bytesInChunk + int64(len(band.stageBuf))
+}
+
+// writeRow encodes cell as a CSV row into the band's staging buffer,
+// flushing to the upload pipe once the buffer is full.
+func (band *columnBand) writeRow(cell []string) error {
+	buf := bytes.NewBuffer(band.stageBuf)
+	csvWriter := csv.NewWriter(buf)
+	if err := csvWriter.Write(cell); err != nil {
+		return fmt.Errorf("failed to encode row: %w", err)
+	}
+	csvWriter.Flush()
+	band.stageBuf = buf.Bytes()
+
+	if len(band.stageBuf) >= stagingBufSize {
+		return band.flush()
+	}
+	return nil
+}
+
+// countingWriteCloser tracks how many bytes actually reach the underlying
+// store, which - unlike the pre-compression byte count used for row
+// offsets - is the true size of the stored object.
+type countingWriteCloser struct {
+	io.WriteCloser
+	n int64
+}
+
+func (c *countingWriteCloser) Write(p []byte) (int, error) {
+	n, err := c.WriteCloser.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (band *columnBand) flush() error {
+	if len(band.stageBuf) == 0 {
+		return nil
+	}
+	n, err := band.writer.Write(band.stageBuf)
+	band.bytesInChunk += int64(n)
+	band.stageBuf = band.stageBuf[:0]
+	return err
+}
+
+func (s *server) uploadHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Parse multipart form, keeping up to 100MB of it in memory (file
+	// parts beyond that are stored in temporary files on disk)
+	if err := r.ParseMultipartForm(100 << 20); err != nil {
+		http.Error(w, fmt.Sprintf("Could not parse multipart form: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// 2. Retrieve file from posted form-data
+	file, handler, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Invalid file key in request", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	// 3. Validate file type by its extension (the MIME type is not checked)
+	if !strings.HasSuffix(strings.ToLower(handler.Filename), ".csv") {
+		http.Error(w, "Invalid file type. Only CSV files are allowed.", http.StatusBadRequest)
+		return
+	}
+
+	// 4. Retrieve docId from form data
+	docIdStr := r.FormValue("docId")
+	if docIdStr == "" {
+		http.Error(w, "Missing 'docId' in form data", http.StatusBadRequest)
+		return
+	}
+
+	// 5. Validate docId is a valid UUID
+	docId, err := uuid.Parse(docIdStr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid docId format: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Received file: %s, Size: %d, docId: %s\n", handler.Filename, handler.Size, docId.String())
+
+	// ctx is canceled on any upload error so every column band's in-flight
+	// multipart upload aborts together, rather than leaving orphaned parts.
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// 5a. Resolve the chunk dimensions and compression for this upload:
+	// server defaults overridden by any chunkRows/chunkCols/compression
+	// form fields on this request.
+	cfg, err := s.defaultChunks.withFormOverrides(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 5b. In "replace" mode, clear out whatever is already stored for this
+	// docId before writing the new chunks, so re-uploading a file with
+	// fewer rows/columns doesn't leave orphaned keys behind.
+	if replace, _ := strconv.ParseBool(r.FormValue("replace")); replace {
+		deleted, err := s.deleteDoc(ctx, docId.String())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to clear existing chunks for docId %s: %v", docId, err), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Replace mode: cleared %d existing object(s) for docId %s", deleted, docId)
+	}
+
+	// 6. Stream the CSV row-by-row instead of buffering the whole file,
+	// hashing the raw bytes as they're read so the manifest can record a
+	// content hash without a second pass over the file.
+	hasher := sha256.New()
+	csvReader := csv.NewReader(io.TeeReader(file, hasher))
+	header, err := csvReader.Read()
+	if err == io.EOF {
+		http.Error(w, "CSV file is empty", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error reading CSV header: %v", err), http.StatusInternalServerError)
+		return
+	}
+	numCols := len(header)
+
+	log.Printf("CSV header has %d columns", numCols)
+
+	// 7. Set up one column band per cfg.cols-wide slice of the sheet.
+	bands := make([]*columnBand, 0, (numCols+cfg.cols-1)/cfg.cols)
+	for colStart := 0; colStart < numCols; colStart += cfg.cols {
+		colEnd := min(colStart+cfg.cols, numCols)
+		bandHeader := append([]string{}, header[colStart:colEnd]...)
+		bands = append(bands, &columnBand{colStart: colStart, colEnd: colEnd, header: bandHeader})
+	}
+
+	var chunks []ChunkInfo
+
+	abortUpload := func() {
+		cancel()
+		for _, band := range bands {
+			if band.writer != nil {
+				band.writer.Close()
+			}
+		}
+	}
+
+	openBand := func(band *columnBand) error {
+		// The key can only name what's known when the upload opens: where
+		// the chunk starts. The final row it actually covers isn't known
+		// until the chunk closes (the last chunk of a band is almost never
+		// a full cfg.rows), so that goes in the manifest's RowEnd instead
+		// of being guessed here.
+		key := fmt.Sprintf("%s/rows_%d_cols_%d-%d.csv%s", docId.String(), band.rowStart, band.colStart, band.colEnd, cfg.compression.extension())
+		rawWriter, err := s.store.PutChunkStream(ctx, key, ChunkMetadata{
+			ContentType:     "text/csv",
+			ContentEncoding: cfg.compression.contentEncoding(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to open chunk upload for cols %d-%d: %w", band.colStart, band.colEnd, err)
+		}
+		counter := &countingWriteCloser{WriteCloser: rawWriter}
+		writer, err := wrapForCompression(counter, cfg.compression)
+		if err != nil {
+			rawWriter.Close()
+			return fmt.Errorf("failed to set up %s compression for cols %d-%d: %w", cfg.compression, band.colStart, band.colEnd, err)
+		}
+		band.key = key
+		band.writer = writer
+		band.counter = counter
+		band.rowsInChunk = 0
+		band.bytesInChunk = 0
+		band.rowOffsets = nil
+		band.stageBuf = stagingBufPool.Get().([]byte)[:0]
+		return band.writeRow(band.header)
+	}
+
+	closeBand := func(band *columnBand) error {
+		if band.writer == nil {
+			return nil
+		}
+		flushErr := band.flush()
+		stagingBufPool.Put(band.stageBuf[:0])
+		band.stageBuf = nil
+		closeErr := band.writer.Close()
+		band.writer = nil
+		if flushErr != nil {
+			return fmt.Errorf("failed to flush chunk for cols %d-%d: %w", band.colStart, band.colEnd, flushErr)
+		}
+		if closeErr != nil {
+			return fmt.Errorf("failed to finalize chunk for cols %d-%d: %w", band.colStart, band.colEnd, closeErr)
+		}
+		// Row byte offsets are only meaningful for uncompressed chunks:
+		// compression makes the object no longer byte-addressable by row,
+		// so readers fall back to downloading the whole chunk for those.
+		var rowByteOffsets []int64
+		if cfg.compression == compressionNone {
+			rowByteOffsets = append(band.rowOffsets, band.bytesInChunk)
+		}
+
+		chunks = append(chunks, ChunkInfo{
+			Key:            band.key,
+			Size:           band.counter.n,
+			RowStart:       band.rowStart,
+			RowEnd:         band.rowStart + band.rowsInChunk - 1,
+			ColStart:       band.colStart,
+			ColEnd:         band.colEnd,
+			RowByteOffsets: rowByteOffsets,
+		})
+		return nil
+	}
+
+	fail := func(format string, args ...any) {
+		abortUpload()
+		http.Error(w, fmt.Sprintf(format, args...), http.StatusInternalServerError)
+	}
+
+	// 8. Stream rows into their column bands, rotating each band to a new
+	// chunk object every cfg.rows rows.
+	rowIndex := 0 // 0-based index into data rows, excluding the header
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fail("Error reading CSV data: %v", err)
+			return
+		}
+
+		for _, band := range bands {
+			if band.writer == nil {
+				band.rowStart = rowIndex
+				if err := openBand(band); err != nil {
+					fail("%v", err)
+					return
+				}
+			}
+
+			actualColEnd := min(band.colEnd, len(record))
+			var cell []string
+			if band.colStart < actualColEnd {
+				cell = record[band.colStart:actualColEnd]
+			}
+			band.rowOffsets = append(band.rowOffsets, band.offset())
+			if err := band.writeRow(cell); err != nil {
+				fail("Failed to stream row %d for cols %d-%d: %v", rowIndex, band.colStart, band.colEnd, err)
+				return
+			}
+
+			band.rowsInChunk++
+			if band.rowsInChunk >= cfg.rows {
+				if err := closeBand(band); err != nil {
+					fail("%v", err)
+					return
+				}
+			}
+		}
+		rowIndex++
+	}
+
+	// 9. Close out any bands left with a partial (final) chunk open.
+	for _, band := range bands {
+		if err := closeBand(band); err != nil {
+			fail("%v", err)
+			return
+		}
+	}
+
+	// 10. Write the manifest describing the chunk layout, so downstream
+	// consumers and a future replace/delete can discover it without
+	// listing the store.
+	manifest := &Manifest{
+		DocId:       docId.String(),
+		Filename:    handler.Filename,
+		ContentHash: hex.EncodeToString(hasher.Sum(nil)),
+		UploadedAt:  time.Now().UTC(),
+		NumRows:     rowIndex,
+		NumCols:     numCols,
+		ChunkRows:   cfg.rows,
+		ChunkCols:   cfg.cols,
+		Compression: string(cfg.compression),
+		Chunks:      chunks,
+	}
+	if err := s.writeManifest(ctx, manifest); err != nil {
+		fail("Failed to write manifest: %v", err)
+		return
+	}
+
+	fmt.Fprintf(w, "File '%s' for docId '%s' processed and chunks uploaded successfully.", handler.Filename, docId.String())
+}